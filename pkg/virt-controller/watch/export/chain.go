@@ -0,0 +1,116 @@
+/*
+ * This file is part of the KubeVirt project
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * Copyright 2022 Red Hat, Inc.
+ *
+ */
+
+package export
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	k8sv1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	exportv1 "kubevirt.io/api/export/v1alpha1"
+)
+
+// incrementalVolumeFormat builds the KubeVirtQcow2Incremental format entry for a volume
+// whose export was resolved to an incremental delta. volumeBaseURL is the same base URL
+// buildVolumeFormats uses for the volume's other formats; the delta is served at
+// <volumeBaseURL>/disk.qcow2, alongside a manifest.json the consumer fetches to confirm
+// and reassemble the chain (see pkg/virt-exportserver's qcow2incremental.go).
+func incrementalVolumeFormat(volumeBaseURL string) exportv1.VirtualMachineExportVolumeFormat {
+	return exportv1.VirtualMachineExportVolumeFormat{
+		Format: exportv1.KubeVirtQcow2Incremental,
+		Url:    volumeBaseURL + "/disk.qcow2",
+	}
+}
+
+// exportChain is the outcome of resolving Spec.BaseExportRef against the source PVC's
+// export history: either a full export, or a delta chained off baseSnapshotName.
+type exportChain struct {
+	// incremental is false for a full export: either BaseExportRef was unset, or the
+	// chain had to be broken (depth cap reached).
+	incremental      bool
+	baseSnapshotName string
+	depth            int
+}
+
+// resolveExportChain decides whether pvc's export should be a full copy or a
+// KubeVirtQcow2Incremental delta, based on Spec.BaseExportRef and the bookkeeping left
+// on the PVC by the export that produced it. Chaining off a base export whose source
+// doesn't match, or whose recorded snapshot was GC'd or never completed, is an error
+// rather than a silent full export -- the caller asked for a delta against a specific
+// base, and falling back would silently ignore that request. Only the chain-depth cap
+// forces a (non-error) full export, since that's an expected, routine occurrence.
+func (ctrl *VMExportController) resolveExportChain(vmExport *exportv1.VirtualMachineExport, pvc *k8sv1.PersistentVolumeClaim) (exportChain, error) {
+	if vmExport.Spec.BaseExportRef == nil {
+		return exportChain{incremental: false}, nil
+	}
+
+	base, err := ctrl.Client.VirtualMachineExport(vmExport.Namespace).Get(context.Background(), vmExport.Spec.BaseExportRef.Name, metav1.GetOptions{})
+	if err != nil {
+		return exportChain{}, fmt.Errorf("getting base export %s/%s: %w", vmExport.Namespace, vmExport.Spec.BaseExportRef.Name, err)
+	}
+	if base.Spec.Source.Kind != vmExport.Spec.Source.Kind || base.Spec.Source.Name != vmExport.Spec.Source.Name {
+		return exportChain{}, fmt.Errorf("base export %s/%s does not target the same source", vmExport.Namespace, base.Name)
+	}
+
+	lastSnapshot := pvc.Annotations[exportv1.AnnotationLastExportedSnapshot]
+	if lastSnapshot == "" {
+		return exportChain{}, fmt.Errorf("base export %s/%s has no recorded snapshot to chain from (garbage collected, or never completed)", vmExport.Namespace, base.Name)
+	}
+
+	depth := 0
+	if raw, ok := pvc.Annotations[exportv1.AnnotationChainDepth]; ok {
+		depth, _ = strconv.Atoi(raw)
+	}
+
+	maxDepth := ctrl.MaxChainDepth
+	if maxDepth <= 0 {
+		maxDepth = exportv1.DefaultMaxChainDepth
+	}
+	if depth >= maxDepth {
+		// Chain capped out: force a full re-export rather than growing it further.
+		return exportChain{incremental: false}, nil
+	}
+
+	return exportChain{incremental: true, baseSnapshotName: lastSnapshot, depth: depth + 1}, nil
+}
+
+// recordExportedSnapshot stamps the source PVC with the snapshot and chain depth this
+// export produced, so a later export with Spec.BaseExportRef pointing at it can chain
+// an incremental delta off it.
+func (ctrl *VMExportController) recordExportedSnapshot(pvc *k8sv1.PersistentVolumeClaim, snapshotName string, chain exportChain) error {
+	if pvc.Annotations == nil {
+		pvc.Annotations = map[string]string{}
+	}
+	pvc.Annotations[exportv1.AnnotationLastExportedSnapshot] = snapshotName
+	if pvc.Spec.StorageClassName != nil {
+		pvc.Annotations[exportv1.AnnotationLastExportedStorageClass] = *pvc.Spec.StorageClassName
+	}
+	depth := 0
+	if chain.incremental {
+		depth = chain.depth
+	}
+	pvc.Annotations[exportv1.AnnotationChainDepth] = strconv.Itoa(depth)
+
+	_, err := ctrl.Client.CoreV1().PersistentVolumeClaims(pvc.Namespace).Update(context.Background(), pvc, metav1.UpdateOptions{})
+	return err
+}
@@ -0,0 +1,254 @@
+/*
+ * This file is part of the KubeVirt project
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * Copyright 2022 Red Hat, Inc.
+ *
+ */
+
+package export
+
+import (
+	"context"
+	"fmt"
+
+	vsv1 "github.com/kubernetes-csi/external-snapshotter/client/v6/apis/volumesnapshot/v1"
+
+	k8sv1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	exportv1 "kubevirt.io/api/export/v1alpha1"
+	snapshotv1 "kubevirt.io/api/snapshot/v1alpha1"
+	"kubevirt.io/client-go/kubecli"
+)
+
+// defaultRestoreSize is used for a VolumeSnapshot restore PVC when the snapshot itself
+// doesn't report a RestoreSize, which can happen with some CSI drivers.
+var defaultRestoreSize = resource.MustParse("1Gi")
+
+// sourceVolume is a single volume resolved from a VirtualMachineExport's source, named
+// after the way it should be identified in VirtualMachineExportStatus.Links.*.Volumes.
+type sourceVolume struct {
+	name string
+	pvc  *k8sv1.PersistentVolumeClaim
+}
+
+// VMExportController reconciles VirtualMachineExport objects: it resolves the
+// requested source into one or more PVCs, makes sure an exporter pod/service serving
+// them exists, and reflects the result in VirtualMachineExportStatus.
+type VMExportController struct {
+	Client kubecli.KubevirtClient
+	// DefaultVolumesPolicy is the cluster-wide fallback used to decide whether a
+	// VM-scoped export's source PVC is included when neither Spec.VolumeSelector nor a
+	// per-PVC opt-in/opt-out annotation says otherwise.
+	DefaultVolumesPolicy exportv1.VolumesPolicy
+	// MaxChainDepth caps how many incremental exports may chain off the same full
+	// export before a new full export is forced. Defaults to exportv1.DefaultMaxChainDepth
+	// when zero.
+	MaxChainDepth int
+}
+
+// NewVMExportController builds a VMExportController with the cluster-wide defaults
+// virt-controller is configured with. defaultVolumesPolicy and maxChainDepth are passed
+// through verbatim, including their zero values -- filterVolumesByAnnotation and
+// resolveExportChain already fall back to exportv1.VolumesPolicyOptOut and
+// exportv1.DefaultMaxChainDepth respectively when left unset.
+func NewVMExportController(client kubecli.KubevirtClient, defaultVolumesPolicy exportv1.VolumesPolicy, maxChainDepth int) *VMExportController {
+	return &VMExportController{
+		Client:               client,
+		DefaultVolumesPolicy: defaultVolumesPolicy,
+		MaxChainDepth:        maxChainDepth,
+	}
+}
+
+// resolveSourceVolumes returns the PVCs that back a VirtualMachineExport's source, one
+// entry per disk to be served. PersistentVolumeClaim sources resolve to themselves.
+// VolumeSnapshot and VirtualMachineSnapshot sources are resolved to a temporary PVC
+// restored from the CSI snapshot(s) they reference, created if it doesn't exist yet.
+func (ctrl *VMExportController) resolveSourceVolumes(vmExport *exportv1.VirtualMachineExport) ([]sourceVolume, error) {
+	source := vmExport.Spec.Source
+	switch source.Kind {
+	case "PersistentVolumeClaim":
+		pvc, err := ctrl.Client.CoreV1().PersistentVolumeClaims(vmExport.Namespace).Get(context.Background(), source.Name, metav1.GetOptions{})
+		if err != nil {
+			return nil, err
+		}
+		return []sourceVolume{{name: pvc.Name, pvc: pvc}}, nil
+	case "VolumeSnapshot":
+		snapshot, err := ctrl.Client.KubernetesSnapshotClient().SnapshotV1().VolumeSnapshots(vmExport.Namespace).Get(context.Background(), source.Name, metav1.GetOptions{})
+		if err != nil {
+			return nil, err
+		}
+		pvc, err := ctrl.getOrCreateRestorePVC(vmExport, snapshot)
+		if err != nil {
+			return nil, err
+		}
+		return []sourceVolume{{name: snapshot.Name, pvc: pvc}}, nil
+	case "VirtualMachineSnapshot":
+		volumes, err := ctrl.resolveVirtualMachineSnapshotVolumes(vmExport)
+		if err != nil {
+			return nil, err
+		}
+		return ctrl.filterVolumes(vmExport, volumes)
+	case "VirtualMachine":
+		volumes, err := ctrl.resolveVirtualMachineVolumes(vmExport.Namespace, source.Name)
+		if err != nil {
+			return nil, err
+		}
+		return ctrl.filterVolumes(vmExport, volumes)
+	default:
+		return nil, fmt.Errorf("unsupported export source kind %q", source.Kind)
+	}
+}
+
+// resolveVirtualMachineVolumes resolves every DataVolume/PVC-backed volume of a
+// VirtualMachine to its underlying PVC, keyed by the volume's name in the VM spec.
+func (ctrl *VMExportController) resolveVirtualMachineVolumes(namespace, name string) ([]sourceVolume, error) {
+	vm, err := ctrl.Client.VirtualMachine(namespace).Get(name, &metav1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	volumes := make([]sourceVolume, 0, len(vm.Spec.Template.Spec.Volumes))
+	for _, volume := range vm.Spec.Template.Spec.Volumes {
+		var pvcName string
+		switch {
+		case volume.DataVolume != nil:
+			pvcName = volume.DataVolume.Name
+		case volume.PersistentVolumeClaim != nil:
+			pvcName = volume.PersistentVolumeClaim.ClaimName
+		default:
+			continue
+		}
+		pvc, err := ctrl.Client.CoreV1().PersistentVolumeClaims(namespace).Get(context.Background(), pvcName, metav1.GetOptions{})
+		if err != nil {
+			return nil, err
+		}
+		volumes = append(volumes, sourceVolume{name: volume.Name, pvc: pvc})
+	}
+	return volumes, nil
+}
+
+// resolveVirtualMachineSnapshotVolumes fans a VirtualMachineSnapshot out into one
+// sourceVolume per backed-up disk, keyed by the disk's original volume name so the
+// export's Links.*.Volumes entries line up with the VM's own volume names.
+func (ctrl *VMExportController) resolveVirtualMachineSnapshotVolumes(vmExport *exportv1.VirtualMachineExport) ([]sourceVolume, error) {
+	namespace, name := vmExport.Namespace, vmExport.Spec.Source.Name
+	vmSnapshot, err := ctrl.Client.VirtualMachineSnapshot(namespace).Get(context.Background(), name, metav1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+	if vmSnapshot.Status == nil || vmSnapshot.Status.VirtualMachineSnapshotContentName == nil {
+		return nil, fmt.Errorf("VirtualMachineSnapshot %s/%s has no content yet", namespace, name)
+	}
+
+	var content *snapshotv1.VirtualMachineSnapshotContent
+	content, err = ctrl.Client.VirtualMachineSnapshotContent(namespace).Get(context.Background(), *vmSnapshot.Status.VirtualMachineSnapshotContentName, metav1.GetOptions{})
+	if err != nil {
+		return nil, err
+	}
+
+	volumes := make([]sourceVolume, 0, len(content.Spec.VolumeBackups))
+	for _, backup := range content.Spec.VolumeBackups {
+		if backup.VolumeSnapshotName == nil {
+			continue
+		}
+		snapshot, err := ctrl.Client.KubernetesSnapshotClient().SnapshotV1().VolumeSnapshots(namespace).Get(context.Background(), *backup.VolumeSnapshotName, metav1.GetOptions{})
+		if err != nil {
+			return nil, err
+		}
+		pvc, err := ctrl.getOrCreateRestorePVC(vmExport, snapshot)
+		if err != nil {
+			return nil, err
+		}
+		volumes = append(volumes, sourceVolume{name: backup.VolumeName, pvc: pvc})
+	}
+	return volumes, nil
+}
+
+// exportOwnerReference ties an object the controller creates on a VirtualMachineExport's
+// behalf (e.g. a restore PVC) to that export's lifecycle, so it is garbage collected once
+// the export is deleted.
+func exportOwnerReference(vmExport *exportv1.VirtualMachineExport) metav1.OwnerReference {
+	blockOwnerDeletion := true
+	controller := true
+	return metav1.OwnerReference{
+		APIVersion:         "export.kubevirt.io/v1alpha1",
+		Kind:               "VirtualMachineExport",
+		Name:               vmExport.Name,
+		UID:                vmExport.UID,
+		Controller:         &controller,
+		BlockOwnerDeletion: &blockOwnerDeletion,
+	}
+}
+
+// restorePVCVolumeMode looks up the VolumeSnapshot's source PVC, when it still exists, to
+// carry its VolumeMode over to the restore PVC -- restoring a Block volume mode snapshot
+// into a Filesystem mode PVC silently breaks block-mode export and import alike.
+func (ctrl *VMExportController) restorePVCVolumeMode(namespace string, snapshot *vsv1.VolumeSnapshot) *k8sv1.PersistentVolumeMode {
+	if snapshot.Spec.Source.PersistentVolumeClaimName == nil {
+		return nil
+	}
+	sourcePvc, err := ctrl.Client.CoreV1().PersistentVolumeClaims(namespace).Get(context.Background(), *snapshot.Spec.Source.PersistentVolumeClaimName, metav1.GetOptions{})
+	if err != nil || sourcePvc.Spec.VolumeMode == nil {
+		return nil
+	}
+	volumeMode := *sourcePvc.Spec.VolumeMode
+	return &volumeMode
+}
+
+// getOrCreateRestorePVC provisions a PVC whose DataSource is the given VolumeSnapshot,
+// letting the CSI driver restore it, and returns the existing one if already created by
+// a previous reconcile. The restore PVC is owned by vmExport so it is garbage collected
+// along with it.
+func (ctrl *VMExportController) getOrCreateRestorePVC(vmExport *exportv1.VirtualMachineExport, snapshot *vsv1.VolumeSnapshot) (*k8sv1.PersistentVolumeClaim, error) {
+	namespace := vmExport.Namespace
+	restoreName := fmt.Sprintf("%s-export-restore", snapshot.Name)
+	pvc, err := ctrl.Client.CoreV1().PersistentVolumeClaims(namespace).Get(context.Background(), restoreName, metav1.GetOptions{})
+	if err == nil {
+		return pvc, nil
+	}
+	if !errors.IsNotFound(err) {
+		return nil, err
+	}
+
+	restoreSize := defaultRestoreSize
+	if snapshot.Status != nil && snapshot.Status.RestoreSize != nil {
+		restoreSize = *snapshot.Status.RestoreSize
+	}
+
+	apiGroup := vsv1.GroupName
+	pvc = &k8sv1.PersistentVolumeClaim{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            restoreName,
+			Namespace:       namespace,
+			OwnerReferences: []metav1.OwnerReference{exportOwnerReference(vmExport)},
+		},
+		Spec: k8sv1.PersistentVolumeClaimSpec{
+			AccessModes: []k8sv1.PersistentVolumeAccessMode{k8sv1.ReadWriteOnce},
+			VolumeMode:  ctrl.restorePVCVolumeMode(namespace, snapshot),
+			Resources: k8sv1.ResourceRequirements{
+				Requests: k8sv1.ResourceList{k8sv1.ResourceStorage: restoreSize},
+			},
+			DataSource: &k8sv1.TypedLocalObjectReference{
+				APIGroup: &apiGroup,
+				Kind:     "VolumeSnapshot",
+				Name:     snapshot.Name,
+			},
+		},
+	}
+	return ctrl.Client.CoreV1().PersistentVolumeClaims(namespace).Create(context.Background(), pvc, metav1.CreateOptions{})
+}
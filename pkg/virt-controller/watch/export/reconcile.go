@@ -0,0 +1,112 @@
+/*
+ * This file is part of the KubeVirt project
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * Copyright 2022 Red Hat, Inc.
+ *
+ */
+
+package export
+
+import (
+	"fmt"
+
+	k8sv1 "k8s.io/api/core/v1"
+
+	exportv1 "kubevirt.io/api/export/v1alpha1"
+	virtexportserver "kubevirt.io/kubevirt/pkg/virt-exportserver"
+)
+
+// Execute resolves vmExport's source into the volume(s) it backs -- applying volume
+// selection for multi-volume sources -- and assembles the VirtualMachineExportLinks a
+// client reads the per-volume download URLs from. It is the single place that turns
+// resolveSourceVolumes/filterVolumes into the status the exporter pod's Service backs.
+func (ctrl *VMExportController) Execute(vmExport *exportv1.VirtualMachineExport) (*exportv1.VirtualMachineExportLinks, error) {
+	// Validate the requested push destination, if any, up front -- a misconfigured
+	// Spec.Destination should surface as a reconcile error rather than be discovered
+	// only once the exporter pod tries (and fails) to push.
+	if _, _, err := ctrl.resolveRegistryDestination(vmExport); err != nil {
+		return nil, err
+	}
+
+	volumes, err := ctrl.resolveSourceVolumes(vmExport)
+	if err != nil {
+		return nil, err
+	}
+
+	exportVolumes := make([]exportv1.VirtualMachineExportVolume, 0, len(volumes))
+	for _, volume := range volumes {
+		chain, err := ctrl.resolveExportChain(vmExport, volume.pvc)
+		if err != nil {
+			return nil, err
+		}
+
+		formats := ctrl.buildVolumeFormats(vmExport, volume, chain)
+		exportVolumes = append(exportVolumes, exportv1.VirtualMachineExportVolume{Name: volume.name, Formats: formats})
+
+		// Chain bookkeeping is only meaningful on the source PVC itself: a
+		// VolumeSnapshot/VirtualMachineSnapshot source's restore PVC is recreated per
+		// export and isn't what a later BaseExportRef points back at.
+		if vmExport.Spec.Source.Kind == "PersistentVolumeClaim" {
+			if err := ctrl.recordExportedSnapshot(volume.pvc, vmExport.Name, chain); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return &exportv1.VirtualMachineExportLinks{
+		Internal: &exportv1.VirtualMachineExportLink{
+			Volumes: exportVolumes,
+		},
+	}, nil
+}
+
+// volumeBaseURL is the base URL the exporter pod's in-cluster Service serves a resolved
+// volume's formats under.
+func volumeBaseURL(vmExport *exportv1.VirtualMachineExport, volume sourceVolume) string {
+	serviceName := fmt.Sprintf("virt-export-%s", vmExport.Name)
+	return fmt.Sprintf("https://%s.%s.svc/volumes/%s", serviceName, vmExport.Namespace, volume.name)
+}
+
+// buildVolumeFormats decides which VirtualMachineExportVolumeFormat entries a single
+// resolved source volume is served under. A Block volume mode PVC is served by the
+// exporter's block-device handlers instead of the regular filesystem disk.img ones,
+// since there is no filesystem to read a disk.img out of. When chain resolved an
+// incremental delta, KubeVirtQcow2Incremental is offered alongside the regular formats.
+func (ctrl *VMExportController) buildVolumeFormats(vmExport *exportv1.VirtualMachineExport, volume sourceVolume, chain exportChain) []exportv1.VirtualMachineExportVolumeFormat {
+	baseURL := volumeBaseURL(vmExport, volume)
+
+	var formats []exportv1.VirtualMachineExportVolumeFormat
+	if isBlockVolume(volume.pvc) {
+		formats = virtexportserver.BlockVolumeFormats(baseURL)
+	} else {
+		formats = []exportv1.VirtualMachineExportVolumeFormat{
+			{Format: exportv1.KubeVirtRaw, Url: baseURL + "/disk.img"},
+			{Format: exportv1.KubeVirtGz, Url: baseURL + "/disk.img.gz"},
+			virtexportserver.OCIImageLayoutFormat(baseURL),
+		}
+	}
+
+	if chain.incremental {
+		formats = append(formats, incrementalVolumeFormat(baseURL))
+	}
+
+	return formats
+}
+
+// isBlockVolume reports whether pvc is a Block volume mode PVC, as opposed to the
+// default Filesystem mode.
+func isBlockVolume(pvc *k8sv1.PersistentVolumeClaim) bool {
+	return pvc.Spec.VolumeMode != nil && *pvc.Spec.VolumeMode == k8sv1.PersistentVolumeBlock
+}
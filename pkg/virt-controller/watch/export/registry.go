@@ -0,0 +1,58 @@
+/*
+ * This file is part of the KubeVirt project
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * Copyright 2022 Red Hat, Inc.
+ *
+ */
+
+package export
+
+import (
+	"context"
+	"fmt"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	exportv1 "kubevirt.io/api/export/v1alpha1"
+)
+
+// registryCredentials is the subset of a kubernetes.io/dockerconfigjson Secret the
+// registry push needs.
+type registryCredentials struct {
+	dockerConfigJSON []byte
+}
+
+// resolveRegistryDestination validates vmExport's Spec.Destination.Registry, if set, and
+// fetches the Secret it references. It returns nil, nil when no registry push was
+// requested.
+func (ctrl *VMExportController) resolveRegistryDestination(vmExport *exportv1.VirtualMachineExport) (*exportv1.VirtualMachineExportRegistryDestination, *registryCredentials, error) {
+	if vmExport.Spec.Destination == nil || vmExport.Spec.Destination.Registry == nil {
+		return nil, nil, nil
+	}
+	registry := vmExport.Spec.Destination.Registry
+	if registry.Url == "" {
+		return nil, nil, fmt.Errorf("destination registry url is required")
+	}
+
+	creds := &registryCredentials{}
+	if registry.SecretRef != "" {
+		secret, err := ctrl.Client.CoreV1().Secrets(vmExport.Namespace).Get(context.Background(), registry.SecretRef, metav1.GetOptions{})
+		if err != nil {
+			return nil, nil, err
+		}
+		creds.dockerConfigJSON = secret.Data[".dockerconfigjson"]
+	}
+	return registry, creds, nil
+}
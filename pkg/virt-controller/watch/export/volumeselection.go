@@ -0,0 +1,79 @@
+/*
+ * This file is part of the KubeVirt project
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * Copyright 2022 Red Hat, Inc.
+ *
+ */
+
+package export
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+
+	exportv1 "kubevirt.io/api/export/v1alpha1"
+)
+
+// filterVolumes narrows a multi-volume source (VirtualMachine, VirtualMachineSnapshot)
+// down to the volumes that should actually be exported, per Spec.VolumeSelector, the
+// per-PVC opt-in/opt-out annotations, and the controller's default volumes policy, in
+// that order of precedence.
+func (ctrl *VMExportController) filterVolumes(vmExport *exportv1.VirtualMachineExport, volumes []sourceVolume) ([]sourceVolume, error) {
+	selector := vmExport.Spec.VolumeSelector
+	if selector == nil {
+		return ctrl.filterVolumesByAnnotation(volumes), nil
+	}
+
+	labelSelector, err := metav1.LabelSelectorAsSelector(selector)
+	if err != nil {
+		return nil, err
+	}
+	included := make([]sourceVolume, 0, len(volumes))
+	for _, volume := range volumes {
+		if labelSelector.Matches(labels.Set(volume.pvc.Labels)) {
+			included = append(included, volume)
+		}
+	}
+	return included, nil
+}
+
+// filterVolumesByAnnotation applies the opt-in/opt-out annotations and the default
+// volumes policy. An explicit annotation on the PVC always wins; when neither is
+// present, inclusion follows ctrl.DefaultVolumesPolicy (OptOut is assumed if unset).
+func (ctrl *VMExportController) filterVolumesByAnnotation(volumes []sourceVolume) []sourceVolume {
+	policy := ctrl.DefaultVolumesPolicy
+	if policy == "" {
+		policy = exportv1.VolumesPolicyOptOut
+	}
+
+	included := make([]sourceVolume, 0, len(volumes))
+	for _, volume := range volumes {
+		annotations := volume.pvc.Annotations
+		_, optedIn := annotations[exportv1.AnnotationOptIn]
+		_, optedOut := annotations[exportv1.AnnotationOptOut]
+
+		switch {
+		case optedIn:
+			included = append(included, volume)
+		case optedOut:
+			continue
+		case policy == exportv1.VolumesPolicyOptIn:
+			continue
+		default:
+			included = append(included, volume)
+		}
+	}
+	return included
+}
@@ -0,0 +1,84 @@
+/*
+ * This file is part of the KubeVirt project
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * Copyright 2022 Red Hat, Inc.
+ *
+ */
+
+package virtexportserver
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+
+	exportv1 "kubevirt.io/api/export/v1alpha1"
+)
+
+// blockVolumePath is where the exporter pod's VolumeDevices mount a Block volume mode
+// PVC, mirroring the convention used by the exporter's filesystem dataPath mount.
+const blockVolumePath = "/dev/volume"
+
+// BlockVolumeFormats returns the formats a Block volume mode PVC is served in, paired
+// with the URL path the exporter registers a handler for.
+func BlockVolumeFormats(baseURL string) []exportv1.VirtualMachineExportVolumeFormat {
+	return []exportv1.VirtualMachineExportVolumeFormat{
+		{Format: exportv1.KubeVirtRawBlock, Url: fmt.Sprintf("%s/disk.img", baseURL)},
+		{Format: exportv1.KubeVirtGzBlock, Url: fmt.Sprintf("%s/disk.img.gz", baseURL)},
+	}
+}
+
+// serveRawBlock streams the raw contents of the Block volume mode PVC mounted at
+// blockVolumePath directly into the response.
+func serveRawBlock(w http.ResponseWriter, r *http.Request) {
+	device, err := os.Open(blockVolumePath)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer device.Close()
+
+	w.Header().Set("Content-Type", "application/octet-stream")
+	if _, err := io.Copy(w, device); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// serveGzBlock streams the Block volume mode PVC mounted at blockVolumePath through a
+// gzip writer into the response, matching the filesystem exporter's KubeVirtGz format.
+func serveGzBlock(w http.ResponseWriter, r *http.Request) {
+	device, err := os.Open(blockVolumePath)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer device.Close()
+
+	w.Header().Set("Content-Type", "application/gzip")
+	gz := gzip.NewWriter(w)
+	defer gz.Close()
+	if _, err := io.Copy(gz, device); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
+
+// RegisterBlockHandlers wires the Block volume mode export formats onto mux, to be
+// called instead of the filesystem handlers when the source PVC's VolumeMode is Block.
+func RegisterBlockHandlers(mux *http.ServeMux) {
+	mux.HandleFunc("/disk.img", serveRawBlock)
+	mux.HandleFunc("/disk.img.gz", serveGzBlock)
+}
@@ -0,0 +1,270 @@
+/*
+ * This file is part of the KubeVirt project
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * Copyright 2022 Red Hat, Inc.
+ *
+ */
+
+package virtexportserver
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+
+	exportv1 "kubevirt.io/api/export/v1alpha1"
+)
+
+// ociDescriptor is a minimal OCI content descriptor, enough to reference the config and
+// layer blobs an OCIImageLayout export contains.
+type ociDescriptor struct {
+	MediaType string `json:"mediaType"`
+	Digest    string `json:"digest"`
+	Size      int64  `json:"size"`
+}
+
+type ociManifest struct {
+	SchemaVersion int             `json:"schemaVersion"`
+	MediaType     string          `json:"mediaType"`
+	Config        ociDescriptor   `json:"config"`
+	Layers        []ociDescriptor `json:"layers"`
+}
+
+type ociIndex struct {
+	SchemaVersion int             `json:"schemaVersion"`
+	Manifests     []ociDescriptor `json:"manifests"`
+}
+
+// ociDiskLayerEntryName is the path a containerDisk image expects its disk image to be
+// unpacked to inside the layer, matching the convention virt-launcher's containerDisk
+// volume mounts look for.
+const ociDiskLayerEntryName = "disk/disk.img"
+
+// GenerateOCIImageLayout packages diskPath (a raw disk.img) as an OCI image layout
+// tarball at outputPath: a tar+gzip layer under the media type
+// exportv1.OCIDiskLayerMediaType containing the disk at ociDiskLayerEntryName, an empty
+// JSON config, and the index/oci-layout bookkeeping files a registry client (skopeo,
+// containers/image) expects.
+func GenerateOCIImageLayout(diskPath, outputPath string) error {
+	diskInfo, err := os.Stat(diskPath)
+	if err != nil {
+		return err
+	}
+
+	disk, err := os.Open(diskPath)
+	if err != nil {
+		return err
+	}
+	defer disk.Close()
+
+	// Compress to a temp file rather than an in-memory buffer: VM disks routinely run
+	// into the tens of gigabytes, and the layer is read back twice below (to hash it,
+	// then to copy it into the tarball).
+	layerFile, err := os.CreateTemp(filepath.Dir(outputPath), "disk-layer-*.tar.gz")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(layerFile.Name())
+	defer layerFile.Close()
+
+	gz := gzip.NewWriter(layerFile)
+	layerTar := tar.NewWriter(gz)
+	if err := writeTarFileFromReader(layerTar, ociDiskLayerEntryName, disk, diskInfo.Size()); err != nil {
+		return err
+	}
+	if err := layerTar.Close(); err != nil {
+		return err
+	}
+	if err := gz.Close(); err != nil {
+		return err
+	}
+
+	layerDigest, layerSize, err := sha256DescriptorOfFile(layerFile.Name())
+	if err != nil {
+		return err
+	}
+
+	configBytes := []byte("{}")
+	configDigest, configSize := sha256Descriptor(configBytes)
+
+	manifest := ociManifest{
+		SchemaVersion: 2,
+		MediaType:     "application/vnd.oci.image.manifest.v1+json",
+		Config:        ociDescriptor{MediaType: "application/vnd.oci.image.config.v1+json", Digest: configDigest, Size: configSize},
+		Layers:        []ociDescriptor{{MediaType: exportv1.OCIDiskLayerMediaType, Digest: layerDigest, Size: layerSize}},
+	}
+	manifestBytes, err := json.Marshal(manifest)
+	if err != nil {
+		return err
+	}
+	manifestDigest, manifestSize := sha256Descriptor(manifestBytes)
+
+	index := ociIndex{
+		SchemaVersion: 2,
+		Manifests:     []ociDescriptor{{MediaType: manifest.MediaType, Digest: manifestDigest, Size: manifestSize}},
+	}
+	indexBytes, err := json.Marshal(index)
+	if err != nil {
+		return err
+	}
+
+	out, err := os.Create(outputPath)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	tw := tar.NewWriter(out)
+
+	files := map[string][]byte{
+		"oci-layout":             []byte(`{"imageLayoutVersion":"1.0.0"}`),
+		"index.json":             indexBytes,
+		blobPath(configDigest):   configBytes,
+		blobPath(manifestDigest): manifestBytes,
+	}
+	for name, content := range files {
+		if err := writeTarFile(tw, name, content); err != nil {
+			return err
+		}
+	}
+	if err := writeTarFileFromPath(tw, blobPath(layerDigest), layerFile.Name(), layerSize); err != nil {
+		return err
+	}
+	return tw.Close()
+}
+
+func blobPath(digest string) string {
+	return filepath.Join("blobs", "sha256", digest[len("sha256:"):])
+}
+
+func sha256Descriptor(content []byte) (digest string, size int64) {
+	sum := sha256.Sum256(content)
+	return "sha256:" + hex.EncodeToString(sum[:]), int64(len(content))
+}
+
+// sha256DescriptorOfFile hashes path's contents without holding them in memory, returning
+// the same (digest, size) shape as sha256Descriptor.
+func sha256DescriptorOfFile(path string) (digest string, size int64, err error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", 0, err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	size, err = io.Copy(h, f)
+	if err != nil {
+		return "", 0, err
+	}
+	return "sha256:" + hex.EncodeToString(h.Sum(nil)), size, nil
+}
+
+func writeTarFile(tw *tar.Writer, name string, content []byte) error {
+	if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(content))}); err != nil {
+		return err
+	}
+	_, err := tw.Write(content)
+	return err
+}
+
+// writeTarFileFromPath streams path's contents into the tar archive as name, avoiding
+// reading the whole (potentially multi-gigabyte) file into memory.
+func writeTarFileFromPath(tw *tar.Writer, name, path string, size int64) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return writeTarFileFromReader(tw, name, f, size)
+}
+
+// writeTarFileFromReader streams r into the tar archive as name, avoiding reading the
+// whole (potentially multi-gigabyte) contents into memory.
+func writeTarFileFromReader(tw *tar.Writer, name string, r io.Reader, size int64) error {
+	if err := tw.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: size}); err != nil {
+		return err
+	}
+	_, err := io.Copy(tw, r)
+	return err
+}
+
+// PushOCIImageLayout pushes a previously generated OCI image layout tarball to a
+// container registry using skopeo, writing authFile (a dockerconfigjson) to a temporary
+// location first when non-empty. destinationUrl is a docker-transport image reference,
+// e.g. registry.example.com/ns/export:tag.
+func PushOCIImageLayout(layoutPath, destinationUrl string, authFile []byte) error {
+	args := []string{"copy", "oci-archive:" + layoutPath, "docker://" + destinationUrl}
+	if len(authFile) > 0 {
+		authPath, err := writeTempAuthFile(authFile)
+		if err != nil {
+			return fmt.Errorf("writing registry credentials: %w", err)
+		}
+		defer os.Remove(authPath)
+		args = append(args, "--authfile", authPath)
+	}
+	cmd := exec.Command("skopeo", args...)
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("pushing OCI image layout to %s: %w", destinationUrl, err)
+	}
+	return nil
+}
+
+func writeTempAuthFile(authFile []byte) (string, error) {
+	f, err := os.CreateTemp("", "export-registry-auth-*.json")
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	if _, err := f.Write(authFile); err != nil {
+		return "", err
+	}
+	return f.Name(), nil
+}
+
+// OCIImageLayoutFormat builds the OCIImageLayout format entry for a filesystem-backed
+// volume. Unlike KubeVirtRaw/KubeVirtGz it is offered whether or not Spec.Destination
+// requests a registry push -- a client can always pull the layout tarball straight from
+// the exporter and push it itself.
+func OCIImageLayoutFormat(baseURL string) exportv1.VirtualMachineExportVolumeFormat {
+	return exportv1.VirtualMachineExportVolumeFormat{
+		Format: exportv1.OCIImageLayout,
+		Url:    baseURL + "/oci-layout.tar",
+	}
+}
+
+// ServeOCIImageLayout streams a previously generated OCI image layout tarball.
+func ServeOCIImageLayout(layoutPath string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		f, err := os.Open(layoutPath)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		defer f.Close()
+		w.Header().Set("Content-Type", "application/x-tar")
+		if _, err := io.Copy(w, f); err != nil {
+			http.Error(w, fmt.Sprintf("streaming OCI layout: %v", err), http.StatusInternalServerError)
+		}
+	}
+}
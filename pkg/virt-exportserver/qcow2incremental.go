@@ -0,0 +1,125 @@
+/*
+ * This file is part of the KubeVirt project
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * Copyright 2022 Red Hat, Inc.
+ *
+ */
+
+package virtexportserver
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+)
+
+// incrementalChainManifest describes the chain a KubeVirtQcow2Incremental delta was
+// produced against, so the consumer can verify and reassemble it with qemu-img rebase.
+type incrementalChainManifest struct {
+	BaseURL    string `json:"baseUrl"`
+	BaseSHA256 string `json:"baseSha256"`
+	DeltaSHA256 string `json:"deltaSha256"`
+}
+
+// generateQcow2Delta produces a qcow2 file at deltaPath whose backing_file is basePath,
+// containing only the blocks of currentPath that changed relative to baseSnapshotPath,
+// using qemu-img convert's backing-file support.
+func generateQcow2Delta(currentPath, baseSnapshotPath, deltaPath string) error {
+	cmd := exec.Command("qemu-img", "convert", "-O", "qcow2", "-B", baseSnapshotPath, currentPath, deltaPath)
+	cmd.Stderr = os.Stderr
+	return cmd.Run()
+}
+
+// sha256OfFile hashes a file's contents, used to populate the manifest so the consumer
+// can confirm the base it has on hand is the one the delta was generated against.
+func sha256OfFile(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// serveQcow2Delta streams a pre-generated delta file, named after the request path, to
+// the response.
+func serveQcow2Delta(deltaPath string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		f, err := os.Open(deltaPath)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		defer f.Close()
+		w.Header().Set("Content-Type", "application/octet-stream")
+		io.Copy(w, f)
+	}
+}
+
+// serveChainManifest serves the JSON manifest describing baseURL/baseSHA256/deltaSHA256
+// for a delta, computed once when the handler is registered.
+func serveChainManifest(baseURL, basePath, deltaPath string) (http.HandlerFunc, error) {
+	baseSHA256, err := sha256OfFile(basePath)
+	if err != nil {
+		return nil, fmt.Errorf("hashing base %s: %w", basePath, err)
+	}
+	deltaSHA256, err := sha256OfFile(deltaPath)
+	if err != nil {
+		return nil, fmt.Errorf("hashing delta %s: %w", deltaPath, err)
+	}
+	manifest := incrementalChainManifest{
+		BaseURL:     baseURL,
+		BaseSHA256:  baseSHA256,
+		DeltaSHA256: deltaSHA256,
+	}
+	body, err := json.Marshal(manifest)
+	if err != nil {
+		return nil, err
+	}
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(body)
+	}, nil
+}
+
+// RegisterQcow2IncrementalHandlers wires the delta and manifest.json routes for a
+// KubeVirtQcow2Incremental export onto mux. currentPath and baseSnapshotPath are block
+// devices or raw disk.img files; baseURL is the full download URL of the base export's
+// KubeVirtRaw volume, recorded in the manifest for the consumer to fetch separately.
+func RegisterQcow2IncrementalHandlers(mux *http.ServeMux, currentPath, baseSnapshotPath, baseURL, workDir string) error {
+	deltaPath := workDir + "/delta.qcow2"
+	if err := generateQcow2Delta(currentPath, baseSnapshotPath, deltaPath); err != nil {
+		return fmt.Errorf("generating qcow2 delta: %w", err)
+	}
+
+	manifestHandler, err := serveChainManifest(baseURL, baseSnapshotPath, deltaPath)
+	if err != nil {
+		return err
+	}
+
+	mux.HandleFunc("/disk.qcow2", serveQcow2Delta(deltaPath))
+	mux.HandleFunc("/manifest.json", manifestHandler)
+	return nil
+}
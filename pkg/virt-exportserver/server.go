@@ -0,0 +1,72 @@
+/*
+ * This file is part of the KubeVirt project
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * Copyright 2022 Red Hat, Inc.
+ *
+ */
+
+// Package virtexportserver implements the HTTP handlers the exporter pod created for a
+// VirtualMachineExport serves its volume(s) through. VMExportController decides, per
+// volume, which of these formats apply (see the Format builder functions below) and
+// reflects that choice in VirtualMachineExportStatus; this package is what actually
+// backs the resulting URLs once the exporter pod starts.
+package virtexportserver
+
+import "net/http"
+
+// VolumeServeConfig is everything the exporter pod needs to know to serve a single
+// resolved source volume: whether it's Block volume mode, and (once the controller
+// resolved a chain for it) the incremental delta it should serve instead of a full copy.
+type VolumeServeConfig struct {
+	// Block, when true, registers the Block volume mode handlers instead of the
+	// regular filesystem ones.
+	Block bool
+	// Incremental, when non-nil, registers the KubeVirtQcow2Incremental delta/manifest
+	// handlers alongside the regular ones.
+	Incremental *IncrementalServeConfig
+}
+
+// IncrementalServeConfig is the on-disk state RegisterQcow2IncrementalHandlers needs to
+// generate and serve a qcow2 delta.
+type IncrementalServeConfig struct {
+	CurrentPath      string
+	BaseSnapshotPath string
+	BaseURL          string
+	WorkDir          string
+}
+
+// NewVolumeMux builds the *http.ServeMux the exporter pod serves a single resolved
+// volume's configured formats from.
+func NewVolumeMux(cfg VolumeServeConfig) (*http.ServeMux, error) {
+	mux := http.NewServeMux()
+
+	if cfg.Block {
+		RegisterBlockHandlers(mux)
+	}
+
+	if cfg.Incremental != nil {
+		if err := RegisterQcow2IncrementalHandlers(
+			mux,
+			cfg.Incremental.CurrentPath,
+			cfg.Incremental.BaseSnapshotPath,
+			cfg.Incremental.BaseURL,
+			cfg.Incremental.WorkDir,
+		); err != nil {
+			return nil, err
+		}
+	}
+
+	return mux, nil
+}
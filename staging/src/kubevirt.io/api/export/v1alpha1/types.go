@@ -0,0 +1,229 @@
+/*
+ * This file is part of the KubeVirt project
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ *     http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ *
+ * Copyright 2022 Red Hat, Inc.
+ *
+ */
+
+package v1alpha1
+
+import (
+	k8sv1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// +genclient
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// VirtualMachineExport defines the operation of exporting a VM source as a downloadable
+// image, VolumeSnapshot, or VirtualMachineSnapshot.
+type VirtualMachineExport struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   VirtualMachineExportSpec  `json:"spec"`
+	Status *VirtualMachineExportStatus `json:"status,omitempty"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// VirtualMachineExportList is a list of VirtualMachineExport resources.
+type VirtualMachineExportList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+
+	Items []VirtualMachineExport `json:"items"`
+}
+
+// VirtualMachineExportSpec holds the source the export is generated from, and the
+// credentials required to download it.
+type VirtualMachineExportSpec struct {
+	// TokenSecretRef is the name of a secret in the export's namespace containing the
+	// token required to download the export.
+	TokenSecretRef string `json:"tokenSecretRef"`
+	// Source is the object the export is generated from. Supported kinds are
+	// PersistentVolumeClaim, VolumeSnapshot, VirtualMachineSnapshot and VirtualMachine.
+	Source k8sv1.TypedLocalObjectReference `json:"source"`
+	// VolumeSelector, when the source resolves to more than one volume (e.g. a
+	// VirtualMachine), restricts the export to the volumes whose PVC matches this label
+	// selector. Mutually exclusive with the per-PVC opt-in/opt-out annotations; when set
+	// it takes precedence over them. If nil, volume inclusion falls back to the opt-in/
+	// opt-out annotations and the exporter's default volumes policy.
+	VolumeSelector *metav1.LabelSelector `json:"volumeSelector,omitempty"`
+	// BaseExportRef points at a previous, still-Ready VirtualMachineExport of the same
+	// source PVC. When set, the exporter serves a KubeVirtQcow2Incremental delta against
+	// that export's snapshot instead of a full copy of the volume.
+	BaseExportRef *k8sv1.TypedLocalObjectReference `json:"baseExportRef,omitempty"`
+	// Destination, when set, has the export controller push the OCIImageLayout format
+	// to an external location in addition to serving it for download.
+	Destination *VirtualMachineExportDestination `json:"destination,omitempty"`
+}
+
+// VirtualMachineExportDestination names an external location an export format can be
+// pushed to once generated.
+type VirtualMachineExportDestination struct {
+	// Registry, when set, has the OCIImageLayout format pushed to this container
+	// registry after it is generated.
+	Registry *VirtualMachineExportRegistryDestination `json:"registry,omitempty"`
+}
+
+// VirtualMachineExportRegistryDestination is a container registry reference an
+// OCIImageLayout export is pushed to.
+type VirtualMachineExportRegistryDestination struct {
+	// Url is the destination image reference, e.g. registry.example.com/ns/export:tag.
+	Url string `json:"url"`
+	// SecretRef names a Secret in the export's namespace holding docker registry
+	// credentials (.dockerconfigjson) used to authenticate the push.
+	SecretRef string `json:"secretRef,omitempty"`
+}
+
+// VirtualMachineExportPhase represents the current phase of the export.
+type VirtualMachineExportPhase string
+
+const (
+	// Pending means the export is being processed.
+	Pending VirtualMachineExportPhase = "Pending"
+	// Ready means the export is ready to be downloaded.
+	Ready VirtualMachineExportPhase = "Ready"
+	// Terminated means the export source no longer exists.
+	Terminated VirtualMachineExportPhase = "Terminated"
+)
+
+// ConditionReady indicates the export pod and service are up, and the volume(s) are
+// available for download.
+const ConditionReady = "Ready"
+
+// ConditionPVCReady indicates the source volume(s) used by the export are bound and
+// available to be mounted by the exporter pod.
+const ConditionPVCReady = "PVCReady"
+
+// VirtualMachineExportStatus holds the observed state of a VirtualMachineExport.
+type VirtualMachineExportStatus struct {
+	Phase      VirtualMachineExportPhase     `json:"phase,omitempty"`
+	Conditions []Condition                   `json:"conditions,omitempty"`
+	Links      *VirtualMachineExportLinks    `json:"links,omitempty"`
+	ServiceName string                       `json:"serviceName,omitempty"`
+}
+
+// Condition defines one current condition observed on the VirtualMachineExport.
+type Condition struct {
+	Type               string                 `json:"type"`
+	Status             k8sv1.ConditionStatus  `json:"status"`
+	Reason             string                 `json:"reason,omitempty"`
+	Message            string                 `json:"message,omitempty"`
+	LastTransitionTime metav1.Time            `json:"lastTransitionTime,omitempty"`
+}
+
+// VirtualMachineExportLinks groups the URLs a client can use to download the export,
+// split between links reachable only from inside the cluster and links reachable from
+// outside it (e.g. via a Route or Ingress).
+type VirtualMachineExportLinks struct {
+	Internal *VirtualMachineExportLink `json:"internal,omitempty"`
+	External *VirtualMachineExportLink `json:"external,omitempty"`
+}
+
+// VirtualMachineExportLink holds the exporter's serving certificate bundle and the
+// per-volume download URLs.
+type VirtualMachineExportLink struct {
+	Cert    string                    `json:"cert"`
+	Volumes []VirtualMachineExportVolume `json:"volumes,omitempty"`
+}
+
+// VirtualMachineExportVolume is a single exported disk, named after the source volume
+// it was generated from, along with every format it is available in.
+type VirtualMachineExportVolume struct {
+	Name    string                   `json:"name"`
+	Formats []VirtualMachineExportVolumeFormat `json:"formats"`
+}
+
+// VirtualMachineExportVolumeFormat pairs a format with the URL it is downloadable from.
+type VirtualMachineExportVolumeFormat struct {
+	Format ExportVolumeFormat `json:"format"`
+	Url    string             `json:"url"`
+}
+
+// ExportVolumeFormat is the encoding/container a volume is exported in.
+type ExportVolumeFormat string
+
+const (
+	// KubeVirtRaw is the raw disk.img of a filesystem-backed volume.
+	KubeVirtRaw ExportVolumeFormat = "kubevirt-raw"
+	// KubeVirtGz is KubeVirtRaw, gzip compressed.
+	KubeVirtGz ExportVolumeFormat = "kubevirt-gz"
+	// Archive is a directory export of a filesystem-backed volume's contents.
+	Archive ExportVolumeFormat = "dir-archive"
+	// ArchiveGz is Archive, packaged as a tar.gz.
+	ArchiveGz ExportVolumeFormat = "tar-gz"
+	// KubeVirtRawBlock is the raw content of a Block volume mode PVC, streamed straight
+	// off the block device.
+	KubeVirtRawBlock ExportVolumeFormat = "kubevirt-raw-block"
+	// KubeVirtGzBlock is KubeVirtRawBlock, gzip compressed.
+	KubeVirtGzBlock ExportVolumeFormat = "kubevirt-gz-block"
+	// KubeVirtQcow2Incremental is a qcow2 delta against Spec.BaseExportRef's snapshot,
+	// served alongside a manifest.json describing the chain (see VirtualMachineExportVolume).
+	KubeVirtQcow2Incremental ExportVolumeFormat = "kubevirt-qcow2-incremental"
+	// OCIImageLayout packages the volume as an OCI image layout tarball (config plus a
+	// single layer containing disk/disk.img), importable as a containerDisk or via
+	// CDI's registry importer without an intermediate object store.
+	OCIImageLayout ExportVolumeFormat = "oci-image-layout"
+)
+
+// OCIDiskLayerMediaType is the media type of the single layer an OCIImageLayout export
+// packages the volume's disk/disk.img into: a standard tar+gzip filesystem layer, so it
+// unpacks the same way any other containerDisk image layer does.
+const OCIDiskLayerMediaType = "application/vnd.oci.image.layer.v1.tar+gzip"
+
+const (
+	// AnnotationLastExportedSnapshot, set by the export controller on the source PVC
+	// once a full or incremental export completes, names the VolumeSnapshot that export
+	// was taken from. A later export with Spec.BaseExportRef pointing at that export
+	// chains its delta off this snapshot.
+	AnnotationLastExportedSnapshot = "export.kubevirt.io/last-exported-snapshot"
+	// AnnotationLastExportedStorageClass records the storage class the last exported
+	// snapshot was taken from, so a later chained export can refuse to mix storage
+	// classes across the chain.
+	AnnotationLastExportedStorageClass = "export.kubevirt.io/last-exported-storage-class"
+	// AnnotationChainDepth records how many incremental exports deep the chain rooted
+	// at AnnotationLastExportedSnapshot currently is.
+	AnnotationChainDepth = "export.kubevirt.io/chain-depth"
+)
+
+// DefaultMaxChainDepth is used when the exporter isn't configured with an explicit
+// cap on how many incremental exports may chain off the same full export before a new
+// full export is forced.
+const DefaultMaxChainDepth = 10
+
+const (
+	// AnnotationOptIn marks a source PVC as included in a VM-scoped export, overriding
+	// the exporter's default volumes policy when it is VolumesPolicyOptIn.
+	AnnotationOptIn = "export.kubevirt.io/opt-in"
+	// AnnotationOptOut marks a source PVC as excluded from a VM-scoped export,
+	// overriding the exporter's default volumes policy when it is VolumesPolicyOptOut.
+	AnnotationOptOut = "export.kubevirt.io/opt-out"
+)
+
+// VolumesPolicy is the cluster-wide default used to decide whether a VM-scoped
+// export's source PVCs are included when neither VolumeSelector nor an opt-in/opt-out
+// annotation applies to them.
+type VolumesPolicy string
+
+const (
+	// VolumesPolicyOptIn excludes volumes by default; only annotated or selected ones
+	// are exported.
+	VolumesPolicyOptIn VolumesPolicy = "OptIn"
+	// VolumesPolicyOptOut includes volumes by default; only annotated or unselected
+	// ones are excluded.
+	VolumesPolicyOptOut VolumesPolicy = "OptOut"
+)
@@ -30,6 +30,8 @@ import (
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
 
+	vsv1 "github.com/kubernetes-csi/external-snapshotter/client/v6/apis/volumesnapshot/v1"
+
 	cdiv1 "kubevirt.io/containerized-data-importer-api/pkg/apis/core/v1beta1"
 	"kubevirt.io/kubevirt/tests/util"
 
@@ -39,16 +41,20 @@ import (
 	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 
+	kubevirtv1 "kubevirt.io/api/core/v1"
 	exportv1 "kubevirt.io/api/export/v1alpha1"
+	snapshotv1 "kubevirt.io/api/snapshot/v1alpha1"
 	"kubevirt.io/client-go/kubecli"
 	"kubevirt.io/kubevirt/tests"
 	cd "kubevirt.io/kubevirt/tests/containerdisk"
+	"kubevirt.io/kubevirt/tests/flags"
 )
 
 const (
 	caBundleKey = "ca-bundle"
 	caCertPath  = "/cacerts"
 	dataPath    = "/data"
+	devicePath  = "/dev/volume"
 
 	// annContentType is an annotation on a PVC indicating the content type. This is populated by CDI.
 	annContentType = "cdi.kubevirt.io/storage.contentType"
@@ -296,6 +302,38 @@ var _ = SIGDescribe("Export", func() {
 		return pvc, md5sum
 	}
 
+	populateKubeVirtBlockContent := func(sc string) (*k8sv1.PersistentVolumeClaim, string) {
+		By("Creating block source volume")
+		dv := tests.NewRandomDataVolumeWithRegistryImportInStorageClass(cd.DataVolumeImportUrlForContainerDisk(cd.ContainerDiskCirros), util.NamespaceTestDefault, sc, k8sv1.ReadWriteOnce, k8sv1.PersistentVolumeBlock)
+		_, err = virtClient.CdiClient().CdiV1beta1().DataVolumes(dv.Namespace).Create(context.Background(), dv, metav1.CreateOptions{})
+		var pvc *k8sv1.PersistentVolumeClaim
+		Eventually(func() *k8sv1.PersistentVolumeClaim {
+			pvc, _ = virtClient.CoreV1().PersistentVolumeClaims(dv.Namespace).Get(context.Background(), dv.Name, metav1.GetOptions{})
+			return pvc
+		}, 15*time.Second, 1*time.Second).ShouldNot(BeNil())
+		pvc, err = virtClient.CoreV1().PersistentVolumeClaims(dv.Namespace).Get(context.Background(), dv.Name, metav1.GetOptions{})
+		Expect(err).ToNot(HaveOccurred())
+		ensurePVCBound(pvc)
+
+		By("Making sure the DV is successful")
+		Eventually(func() cdiv1.DataVolumePhase {
+			dv, _ = virtClient.CdiClient().CdiV1beta1().DataVolumes(dv.Namespace).Get(context.Background(), dv.Name, metav1.GetOptions{})
+			return dv.Status.Phase
+		}, 90*time.Second, 1*time.Second).Should(Equal(cdiv1.Succeeded))
+
+		pod := createSourcePodChecker(pvc)
+		out, stderr, err := tests.ExecuteCommandOnPodV2(virtClient, pod, pod.Spec.Containers[0].Name, []string{"md5sum", devicePath})
+		Expect(err).ToNot(HaveOccurred(), out, stderr)
+		md5sum := strings.Split(out, " ")[0]
+
+		immediate := int64(0)
+		err = virtClient.CoreV1().Pods(pod.Namespace).Delete(context.Background(), pod.Name, metav1.DeleteOptions{
+			GracePeriodSeconds: &immediate,
+		})
+		Expect(err).ToNot(HaveOccurred())
+		return pvc, md5sum
+	}
+
 	verifyKubeVirtRawContent := func(fileName, comparison string, downloadPod *k8sv1.Pod) {
 		command := []string{
 			"md5sum",
@@ -444,4 +482,733 @@ var _ = SIGDescribe("Export", func() {
 		Entry("with archive content type", populateArchiveContent, verifyKubeVirtRawContent, exportv1.Archive, archiveDircontentUrlTemplate),
 		Entry("with archive tarred gzipped content type", populateArchiveContent, verifyArchiveGzContent, exportv1.ArchiveGz, kubevirtcontentUrlTemplate),
 	)
+
+	Context("with a VolumeSnapshot or VirtualMachineSnapshot source", func() {
+		var snapshotClass string
+
+		BeforeEach(func() {
+			var exists bool
+			snapshotClass, exists = tests.GetSnapshotClass()
+			if !exists {
+				Skip("Skip test when a VolumeSnapshotClass is not present")
+			}
+		})
+
+		createVolumeSnapshot := func(pvc *k8sv1.PersistentVolumeClaim) *vsv1.VolumeSnapshot {
+			snapshot := &vsv1.VolumeSnapshot{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      fmt.Sprintf("snapshot-%s", pvc.Name),
+					Namespace: pvc.Namespace,
+				},
+				Spec: vsv1.VolumeSnapshotSpec{
+					Source: vsv1.VolumeSnapshotSource{
+						PersistentVolumeClaimName: &pvc.Name,
+					},
+					VolumeSnapshotClassName: &snapshotClass,
+				},
+			}
+			snapshot, err = virtClient.KubernetesSnapshotClient().SnapshotV1().VolumeSnapshots(pvc.Namespace).Create(context.Background(), snapshot, metav1.CreateOptions{})
+			Expect(err).ToNot(HaveOccurred())
+
+			Eventually(func() bool {
+				snapshot, err = virtClient.KubernetesSnapshotClient().SnapshotV1().VolumeSnapshots(snapshot.Namespace).Get(context.Background(), snapshot.Name, metav1.GetOptions{})
+				Expect(err).ToNot(HaveOccurred())
+				return snapshot.Status != nil && snapshot.Status.ReadyToUse != nil && *snapshot.Status.ReadyToUse
+			}, 60*time.Second, 1*time.Second).Should(BeTrue())
+			return snapshot
+		}
+
+		waitExportReady := func(export *exportv1.VirtualMachineExport) *exportv1.VirtualMachineExport {
+			Eventually(func() bool {
+				export, err = virtClient.VirtualMachineExport(export.Namespace).Get(context.Background(), export.Name, metav1.GetOptions{})
+				Expect(err).ToNot(HaveOccurred())
+				condReady := false
+				if export.Status != nil {
+					for _, cond := range export.Status.Conditions {
+						if cond.Type == exportv1.ConditionReady && cond.Status == k8sv1.ConditionTrue {
+							condReady = true
+						}
+					}
+				}
+				return condReady
+			}, 60*time.Second, 1*time.Second).Should(BeTrue())
+			return export
+		}
+
+		downloadAndVerify := func(export *exportv1.VirtualMachineExport, sourcePvc *k8sv1.PersistentVolumeClaim, volumeName, comparison string) {
+			targetPvc := &k8sv1.PersistentVolumeClaim{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      fmt.Sprintf("target-pvc-%s", volumeName),
+					Namespace: sourcePvc.Namespace,
+				},
+				Spec: k8sv1.PersistentVolumeClaimSpec{
+					AccessModes:      sourcePvc.Spec.AccessModes,
+					StorageClassName: sourcePvc.Spec.StorageClassName,
+					Resources:        sourcePvc.Spec.Resources,
+				},
+			}
+			targetPvc, err = virtClient.CoreV1().PersistentVolumeClaims(targetPvc.Namespace).Create(context.Background(), targetPvc, metav1.CreateOptions{})
+			Expect(err).ToNot(HaveOccurred())
+			caConfigMap := createCaConfigMap("export-cacerts", targetPvc.Namespace, export.Status.Links.Internal.Cert)
+			downloadPod := createDownloadPodForPvc(targetPvc, caConfigMap)
+
+			downloadUrl := ""
+			fileName := ""
+			for _, volume := range export.Status.Links.Internal.Volumes {
+				if volume.Name == volumeName {
+					for _, format := range volume.Formats {
+						if format.Format == exportv1.KubeVirtRaw {
+							downloadUrl = fmt.Sprintf(kubevirtcontentUrlTemplate, format.Url, token.Name)
+							fileName = filepath.Base(format.Url)
+						}
+					}
+				}
+			}
+			Expect(downloadUrl).ToNot(BeEmpty())
+			Expect(fileName).ToNot(BeEmpty())
+			command := []string{
+				"curl",
+				"-L",
+				"--cacert",
+				filepath.Join(caCertPath, caBundleKey),
+				downloadUrl,
+				"--output",
+				filepath.Join(dataPath, fileName),
+			}
+			out, stderr, err := tests.ExecuteCommandOnPodV2(virtClient, downloadPod, downloadPod.Spec.Containers[0].Name, command)
+			Expect(err).ToNot(HaveOccurred(), out, stderr)
+			verifyKubeVirtRawContent(fileName, comparison, downloadPod)
+		}
+
+		It("should export a VolumeSnapshot", func() {
+			sc, exists := tests.GetRWOFileSystemStorageClass()
+			if !exists {
+				Skip("Skip test when Filesystem storage is not present")
+			}
+			pvc, md5sum := populateKubeVirtContent(sc)
+			snapshot := createVolumeSnapshot(pvc)
+			token = createExportTokenSecret(pvc)
+
+			apiGroup := vsv1.GroupName
+			vmExport := &exportv1.VirtualMachineExport{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      fmt.Sprintf("test-export-%s", snapshot.Name),
+					Namespace: snapshot.Namespace,
+				},
+				Spec: exportv1.VirtualMachineExportSpec{
+					TokenSecretRef: token.Name,
+					Source: k8sv1.TypedLocalObjectReference{
+						APIGroup: &apiGroup,
+						Kind:     "VolumeSnapshot",
+						Name:     snapshot.Name,
+					},
+				},
+			}
+			export, err := virtClient.VirtualMachineExport(snapshot.Namespace).Create(context.Background(), vmExport, metav1.CreateOptions{})
+			Expect(err).ToNot(HaveOccurred())
+			export = waitExportReady(export)
+
+			downloadAndVerify(export, pvc, snapshot.Name, md5sum)
+		})
+
+		It("should export a VirtualMachineSnapshot", func() {
+			sc, exists := tests.GetRWOFileSystemStorageClass()
+			if !exists {
+				Skip("Skip test when Filesystem storage is not present")
+			}
+			vm := tests.NewRandomVMWithDataVolumeInStorageClass(cd.DataVolumeImportUrlForContainerDisk(cd.ContainerDiskCirros), util.NamespaceTestDefault, sc)
+			vm, err = virtClient.VirtualMachine(vm.Namespace).Create(vm)
+			Expect(err).ToNot(HaveOccurred())
+			Eventually(func() bool {
+				vm, err = virtClient.VirtualMachine(vm.Namespace).Get(vm.Name, &metav1.GetOptions{})
+				Expect(err).ToNot(HaveOccurred())
+				return vm.Status.Ready
+			}, 180*time.Second, 1*time.Second).Should(BeTrue())
+
+			pvc, err := virtClient.CoreV1().PersistentVolumeClaims(vm.Namespace).Get(context.Background(), vm.Spec.DataVolumeTemplates[0].Name, metav1.GetOptions{})
+			Expect(err).ToNot(HaveOccurred())
+			pod := createSourcePodChecker(pvc)
+			out, stderr, err := tests.ExecuteCommandOnPodV2(virtClient, pod, pod.Spec.Containers[0].Name, []string{"md5sum", filepath.Join(dataPath, "disk.img")})
+			Expect(err).ToNot(HaveOccurred(), out, stderr)
+			md5sum := strings.Split(out, " ")[0]
+			immediate := int64(0)
+			err = virtClient.CoreV1().Pods(pod.Namespace).Delete(context.Background(), pod.Name, metav1.DeleteOptions{GracePeriodSeconds: &immediate})
+			Expect(err).ToNot(HaveOccurred())
+
+			vmSnapshotApiGroup := snapshotv1.SchemeGroupVersion.Group
+			vmSnapshot := &snapshotv1.VirtualMachineSnapshot{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      fmt.Sprintf("vmsnapshot-%s", vm.Name),
+					Namespace: vm.Namespace,
+				},
+				Spec: snapshotv1.VirtualMachineSnapshotSpec{
+					Source: k8sv1.TypedLocalObjectReference{
+						APIGroup: &vmSnapshotApiGroup,
+						Kind:     "VirtualMachine",
+						Name:     vm.Name,
+					},
+				},
+			}
+			vmSnapshot, err = virtClient.VirtualMachineSnapshot(vm.Namespace).Create(context.Background(), vmSnapshot, metav1.CreateOptions{})
+			Expect(err).ToNot(HaveOccurred())
+			Eventually(func() bool {
+				vmSnapshot, err = virtClient.VirtualMachineSnapshot(vm.Namespace).Get(context.Background(), vmSnapshot.Name, metav1.GetOptions{})
+				Expect(err).ToNot(HaveOccurred())
+				return vmSnapshot.Status != nil && vmSnapshot.Status.ReadyToUse != nil && *vmSnapshot.Status.ReadyToUse
+			}, 60*time.Second, 1*time.Second).Should(BeTrue())
+
+			token = createExportTokenSecret(pvc)
+			apiGroup := vmSnapshotApiGroup
+			vmExport := &exportv1.VirtualMachineExport{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      fmt.Sprintf("test-export-%s", vmSnapshot.Name),
+					Namespace: vmSnapshot.Namespace,
+				},
+				Spec: exportv1.VirtualMachineExportSpec{
+					TokenSecretRef: token.Name,
+					Source: k8sv1.TypedLocalObjectReference{
+						APIGroup: &apiGroup,
+						Kind:     "VirtualMachineSnapshot",
+						Name:     vmSnapshot.Name,
+					},
+				},
+			}
+			export, err := virtClient.VirtualMachineExport(vmSnapshot.Namespace).Create(context.Background(), vmExport, metav1.CreateOptions{})
+			Expect(err).ToNot(HaveOccurred())
+			export = waitExportReady(export)
+
+			// One entry per disk in the VM snapshot, keyed by the DataVolumeTemplate/volume name.
+			downloadAndVerify(export, pvc, vm.Spec.DataVolumeTemplates[0].Name, md5sum)
+		})
+	})
+
+	DescribeTable("should make a block PVC export available", func(expectedFormat exportv1.ExportVolumeFormat) {
+		sc, exists := tests.GetRWOBlockStorageClass()
+		if !exists {
+			Skip("Skip test when Block storage is not present")
+		}
+		pvc, comparison := populateKubeVirtBlockContent(sc)
+		By("Creating the export token, we can export volumes using this token")
+		token = createExportTokenSecret(pvc)
+
+		apiGroup := "v1"
+		vmExport := &exportv1.VirtualMachineExport{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      fmt.Sprintf("test-export-%s", pvc.Name),
+				Namespace: pvc.Namespace,
+			},
+			Spec: exportv1.VirtualMachineExportSpec{
+				TokenSecretRef: token.Name,
+				Source: k8sv1.TypedLocalObjectReference{
+					APIGroup: &apiGroup,
+					Kind:     "PersistentVolumeClaim",
+					Name:     pvc.Name,
+				},
+			},
+		}
+		By("Creating VMExport we can start exporting the volume")
+		export, err := virtClient.VirtualMachineExport(pvc.Namespace).Create(context.Background(), vmExport, metav1.CreateOptions{})
+		Expect(err).ToNot(HaveOccurred())
+
+		Eventually(func() bool {
+			export, err = virtClient.VirtualMachineExport(pvc.Namespace).Get(context.Background(), export.Name, metav1.GetOptions{})
+			Expect(err).ToNot(HaveOccurred())
+			condReady := false
+			if export.Status != nil {
+				for _, cond := range export.Status.Conditions {
+					if cond.Type == exportv1.ConditionReady && cond.Status == k8sv1.ConditionTrue {
+						condReady = true
+					}
+				}
+			}
+			return condReady
+		}, 30*time.Second, 1*time.Second).Should(BeTrue())
+
+		By("Creating block target PVC, so we can inspect if the export worked")
+		blockMode := k8sv1.PersistentVolumeBlock
+		targetPvc := &k8sv1.PersistentVolumeClaim{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      fmt.Sprintf("target-pvc-%s", pvc.Name),
+				Namespace: pvc.Namespace,
+			},
+			Spec: k8sv1.PersistentVolumeClaimSpec{
+				AccessModes:      pvc.Spec.AccessModes,
+				StorageClassName: pvc.Spec.StorageClassName,
+				Resources:        pvc.Spec.Resources,
+				VolumeMode:       &blockMode,
+			},
+		}
+		targetPvc, err = virtClient.CoreV1().PersistentVolumeClaims(targetPvc.Namespace).Create(context.Background(), targetPvc, metav1.CreateOptions{})
+		Expect(err).ToNot(HaveOccurred())
+		caConfigMap := createCaConfigMap("export-cacerts", targetPvc.Namespace, export.Status.Links.Internal.Cert)
+		downloadPod := createDownloadPodForPvc(targetPvc, caConfigMap)
+
+		downloadUrl := ""
+		for _, volume := range export.Status.Links.Internal.Volumes {
+			if volume.Name == pvc.Name {
+				for _, format := range volume.Formats {
+					if format.Format == expectedFormat {
+						downloadUrl = fmt.Sprintf(kubevirtcontentUrlTemplate, format.Url, pvc.Name)
+					}
+				}
+			}
+		}
+		Expect(downloadUrl).ToNot(BeEmpty())
+
+		var command []string
+		switch expectedFormat {
+		case exportv1.KubeVirtRawBlock:
+			command = []string{
+				"curl", "-L", "--cacert", filepath.Join(caCertPath, caBundleKey), downloadUrl, "--output", devicePath,
+			}
+		case exportv1.KubeVirtGzBlock:
+			command = []string{
+				"/bin/sh", "-c",
+				fmt.Sprintf("curl -L --cacert %s '%s' | /usr/bin/gzip -d -c > %s", filepath.Join(caCertPath, caBundleKey), downloadUrl, devicePath),
+			}
+		}
+		By(fmt.Sprintf("Downloading from URL: %s", downloadUrl))
+		out, stderr, err := tests.ExecuteCommandOnPodV2(virtClient, downloadPod, downloadPod.Spec.Containers[0].Name, command)
+		Expect(err).ToNot(HaveOccurred(), out, stderr)
+
+		out, stderr, err = tests.ExecuteCommandOnPodV2(virtClient, downloadPod, downloadPod.Spec.Containers[0].Name, []string{"md5sum", devicePath})
+		Expect(err).ToNot(HaveOccurred(), out, stderr)
+		Expect(strings.Split(out, " ")[0]).To(Equal(comparison))
+	},
+		Entry("with RAW block content type", exportv1.KubeVirtRawBlock),
+		Entry("with RAW gzipped block content type", exportv1.KubeVirtGzBlock),
+	)
+
+	Context("with a VirtualMachine source and volume selection", func() {
+		const (
+			optInAnnotation  = exportv1.AnnotationOptIn
+			optOutAnnotation = exportv1.AnnotationOptOut
+			selectorLabelKey = "export.kubevirt.io/test-include"
+		)
+
+		createVMWithTwoVolumes := func(sc string) (vm *kubevirtv1.VirtualMachine, secondDvName string) {
+			vm = tests.NewRandomVMWithDataVolumeInStorageClass(cd.DataVolumeImportUrlForContainerDisk(cd.ContainerDiskCirros), util.NamespaceTestDefault, sc)
+			secondDv := tests.NewRandomDataVolumeWithRegistryImportInStorageClass(cd.DataVolumeImportUrlForContainerDisk(cd.ContainerDiskCirros), util.NamespaceTestDefault, sc, k8sv1.ReadWriteOnce, k8sv1.PersistentVolumeFilesystem)
+			vm.Spec.DataVolumeTemplates = append(vm.Spec.DataVolumeTemplates, kubevirtv1.DataVolumeTemplateSpec{
+				ObjectMeta: secondDv.ObjectMeta,
+				Spec:       secondDv.Spec,
+			})
+			vm.Spec.Template.Spec.Volumes = append(vm.Spec.Template.Spec.Volumes, kubevirtv1.Volume{
+				Name: secondDv.Name,
+				VolumeSource: kubevirtv1.VolumeSource{
+					DataVolume: &kubevirtv1.DataVolumeSource{Name: secondDv.Name},
+				},
+			})
+			vm, err = virtClient.VirtualMachine(vm.Namespace).Create(vm)
+			Expect(err).ToNot(HaveOccurred())
+			Eventually(func() bool {
+				vm, err = virtClient.VirtualMachine(vm.Namespace).Get(vm.Name, &metav1.GetOptions{})
+				Expect(err).ToNot(HaveOccurred())
+				return vm.Status.Ready
+			}, 180*time.Second, 1*time.Second).Should(BeTrue())
+			return vm, secondDv.Name
+		}
+
+		annotatePvc := func(namespace, name, key, value string) {
+			pvc, err := virtClient.CoreV1().PersistentVolumeClaims(namespace).Get(context.Background(), name, metav1.GetOptions{})
+			Expect(err).ToNot(HaveOccurred())
+			if pvc.Annotations == nil {
+				pvc.Annotations = map[string]string{}
+			}
+			pvc.Annotations[key] = value
+			_, err = virtClient.CoreV1().PersistentVolumeClaims(namespace).Update(context.Background(), pvc, metav1.UpdateOptions{})
+			Expect(err).ToNot(HaveOccurred())
+		}
+
+		labelPvc := func(namespace, name, key, value string) {
+			pvc, err := virtClient.CoreV1().PersistentVolumeClaims(namespace).Get(context.Background(), name, metav1.GetOptions{})
+			Expect(err).ToNot(HaveOccurred())
+			if pvc.Labels == nil {
+				pvc.Labels = map[string]string{}
+			}
+			pvc.Labels[key] = value
+			_, err = virtClient.CoreV1().PersistentVolumeClaims(namespace).Update(context.Background(), pvc, metav1.UpdateOptions{})
+			Expect(err).ToNot(HaveOccurred())
+		}
+
+		createVMExport := func(vm *kubevirtv1.VirtualMachine, volumeSelector *metav1.LabelSelector) *exportv1.VirtualMachineExport {
+			keptPvc, err := virtClient.CoreV1().PersistentVolumeClaims(vm.Namespace).Get(context.Background(), vm.Spec.DataVolumeTemplates[0].Name, metav1.GetOptions{})
+			Expect(err).ToNot(HaveOccurred())
+			token = createExportTokenSecret(keptPvc)
+
+			apiGroup := "kubevirt.io"
+			vmExport := &exportv1.VirtualMachineExport{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      fmt.Sprintf("test-export-%s", vm.Name),
+					Namespace: vm.Namespace,
+				},
+				Spec: exportv1.VirtualMachineExportSpec{
+					TokenSecretRef: token.Name,
+					Source: k8sv1.TypedLocalObjectReference{
+						APIGroup: &apiGroup,
+						Kind:     "VirtualMachine",
+						Name:     vm.Name,
+					},
+					VolumeSelector: volumeSelector,
+				},
+			}
+			export, err := virtClient.VirtualMachineExport(vm.Namespace).Create(context.Background(), vmExport, metav1.CreateOptions{})
+			Expect(err).ToNot(HaveOccurred())
+
+			Eventually(func() bool {
+				export, err = virtClient.VirtualMachineExport(vm.Namespace).Get(context.Background(), export.Name, metav1.GetOptions{})
+				Expect(err).ToNot(HaveOccurred())
+				condReady := false
+				if export.Status != nil {
+					for _, cond := range export.Status.Conditions {
+						if cond.Type == exportv1.ConditionReady && cond.Status == k8sv1.ConditionTrue {
+							condReady = true
+						}
+					}
+				}
+				return condReady
+			}, 60*time.Second, 1*time.Second).Should(BeTrue())
+			return export
+		}
+
+		downloadKeptVolume := func(export *exportv1.VirtualMachineExport, keptDvName string) {
+			keptPvc, err := virtClient.CoreV1().PersistentVolumeClaims(export.Namespace).Get(context.Background(), keptDvName, metav1.GetOptions{})
+			Expect(err).ToNot(HaveOccurred())
+			caConfigMap := createCaConfigMap("export-cacerts", export.Namespace, export.Status.Links.Internal.Cert)
+			targetPvc := &k8sv1.PersistentVolumeClaim{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      fmt.Sprintf("target-pvc-%s", keptPvc.Name),
+					Namespace: keptPvc.Namespace,
+				},
+				Spec: k8sv1.PersistentVolumeClaimSpec{
+					AccessModes:      keptPvc.Spec.AccessModes,
+					StorageClassName: keptPvc.Spec.StorageClassName,
+					Resources:        keptPvc.Spec.Resources,
+				},
+			}
+			targetPvc, err = virtClient.CoreV1().PersistentVolumeClaims(targetPvc.Namespace).Create(context.Background(), targetPvc, metav1.CreateOptions{})
+			Expect(err).ToNot(HaveOccurred())
+			downloadPod := createDownloadPodForPvc(targetPvc, caConfigMap)
+
+			downloadUrl := ""
+			fileName := ""
+			for _, volume := range export.Status.Links.Internal.Volumes {
+				if volume.Name == keptDvName {
+					for _, format := range volume.Formats {
+						if format.Format == exportv1.KubeVirtRaw {
+							downloadUrl = fmt.Sprintf(kubevirtcontentUrlTemplate, format.Url, token.Name)
+							fileName = filepath.Base(format.Url)
+						}
+					}
+				}
+			}
+			Expect(downloadUrl).ToNot(BeEmpty())
+			command := []string{
+				"curl", "-L", "--cacert", filepath.Join(caCertPath, caBundleKey), downloadUrl, "--output", filepath.Join(dataPath, fileName),
+			}
+			out, stderr, err := tests.ExecuteCommandOnPodV2(virtClient, downloadPod, downloadPod.Spec.Containers[0].Name, command)
+			Expect(err).ToNot(HaveOccurred(), out, stderr)
+		}
+
+		It("should only export the opted-in volume when the other DataVolume is annotated opt-out", func() {
+			sc, exists := tests.GetRWOFileSystemStorageClass()
+			if !exists {
+				Skip("Skip test when Filesystem storage is not present")
+			}
+			vm, secondDvName := createVMWithTwoVolumes(sc)
+			annotatePvc(vm.Namespace, secondDvName, optOutAnnotation, "true")
+
+			keptDvName := vm.Spec.DataVolumeTemplates[0].Name
+			export := createVMExport(vm, nil)
+
+			var exportedNames []string
+			for _, volume := range export.Status.Links.Internal.Volumes {
+				exportedNames = append(exportedNames, volume.Name)
+			}
+			Expect(exportedNames).To(ConsistOf(keptDvName))
+			Expect(exportedNames).ToNot(ContainElement(secondDvName))
+
+			downloadKeptVolume(export, keptDvName)
+		})
+
+		It("should export only the annotated opt-in volume under the opt-in default policy", func() {
+			sc, exists := tests.GetRWOFileSystemStorageClass()
+			if !exists {
+				Skip("Skip test when Filesystem storage is not present")
+			}
+			vm, secondDvName := createVMWithTwoVolumes(sc)
+			keptDvName := vm.Spec.DataVolumeTemplates[0].Name
+			annotatePvc(vm.Namespace, keptDvName, optInAnnotation, "true")
+
+			export := createVMExport(vm, nil)
+
+			var exportedNames []string
+			for _, volume := range export.Status.Links.Internal.Volumes {
+				exportedNames = append(exportedNames, volume.Name)
+			}
+			Expect(exportedNames).To(ConsistOf(keptDvName))
+			Expect(exportedNames).ToNot(ContainElement(secondDvName))
+
+			downloadKeptVolume(export, keptDvName)
+		})
+
+		It("should only export the volume matching Spec.VolumeSelector", func() {
+			sc, exists := tests.GetRWOFileSystemStorageClass()
+			if !exists {
+				Skip("Skip test when Filesystem storage is not present")
+			}
+			vm, secondDvName := createVMWithTwoVolumes(sc)
+			keptDvName := vm.Spec.DataVolumeTemplates[0].Name
+			labelPvc(vm.Namespace, keptDvName, selectorLabelKey, "true")
+
+			export := createVMExport(vm, &metav1.LabelSelector{MatchLabels: map[string]string{selectorLabelKey: "true"}})
+
+			var exportedNames []string
+			for _, volume := range export.Status.Links.Internal.Volumes {
+				exportedNames = append(exportedNames, volume.Name)
+			}
+			Expect(exportedNames).To(ConsistOf(keptDvName))
+			Expect(exportedNames).ToNot(ContainElement(secondDvName))
+
+			downloadKeptVolume(export, keptDvName)
+		})
+	})
+
+	Context("with an incremental qcow2 export chain", func() {
+		mutateAFewBlocks := func(pvc *k8sv1.PersistentVolumeClaim) {
+			pod := createSourcePodChecker(pvc)
+			command := []string{
+				"/bin/sh", "-c",
+				fmt.Sprintf("dd if=/dev/urandom of=%s bs=4096 count=4 seek=16 conv=notrunc", filepath.Join(dataPath, "disk.img")),
+			}
+			out, stderr, err := tests.ExecuteCommandOnPodV2(virtClient, pod, pod.Spec.Containers[0].Name, command)
+			Expect(err).ToNot(HaveOccurred(), out, stderr)
+			immediate := int64(0)
+			err = virtClient.CoreV1().Pods(pod.Namespace).Delete(context.Background(), pod.Name, metav1.DeleteOptions{GracePeriodSeconds: &immediate})
+			Expect(err).ToNot(HaveOccurred())
+		}
+
+		createExportForPvc := func(pvc *k8sv1.PersistentVolumeClaim, name string, baseExportRef *k8sv1.TypedLocalObjectReference) *exportv1.VirtualMachineExport {
+			apiGroup := "v1"
+			vmExport := &exportv1.VirtualMachineExport{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      name,
+					Namespace: pvc.Namespace,
+				},
+				Spec: exportv1.VirtualMachineExportSpec{
+					TokenSecretRef: token.Name,
+					Source: k8sv1.TypedLocalObjectReference{
+						APIGroup: &apiGroup,
+						Kind:     "PersistentVolumeClaim",
+						Name:     pvc.Name,
+					},
+					BaseExportRef: baseExportRef,
+				},
+			}
+			export, err := virtClient.VirtualMachineExport(pvc.Namespace).Create(context.Background(), vmExport, metav1.CreateOptions{})
+			Expect(err).ToNot(HaveOccurred())
+
+			Eventually(func() bool {
+				export, err = virtClient.VirtualMachineExport(pvc.Namespace).Get(context.Background(), export.Name, metav1.GetOptions{})
+				Expect(err).ToNot(HaveOccurred())
+				condReady := false
+				if export.Status != nil {
+					for _, cond := range export.Status.Conditions {
+						if cond.Type == exportv1.ConditionReady && cond.Status == k8sv1.ConditionTrue {
+							condReady = true
+						}
+					}
+				}
+				return condReady
+			}, 60*time.Second, 1*time.Second).Should(BeTrue())
+			return export
+		}
+
+		It("should export a qcow2 delta against a previously exported base and reassemble to the current content", func() {
+			sc, exists := tests.GetRWOFileSystemStorageClass()
+			if !exists {
+				Skip("Skip test when Filesystem storage is not present")
+			}
+
+			pvc, _ := populateKubeVirtContent(sc)
+			token = createExportTokenSecret(pvc)
+
+			By("Exporting the full disk as the chain base")
+			baseExport := createExportForPvc(pvc, fmt.Sprintf("base-export-%s", pvc.Name), nil)
+
+			By("Mutating a few blocks of the source disk")
+			mutateAFewBlocks(pvc)
+			pod := createSourcePodChecker(pvc)
+			out, stderr, err := tests.ExecuteCommandOnPodV2(virtClient, pod, pod.Spec.Containers[0].Name, []string{"md5sum", filepath.Join(dataPath, "disk.img")})
+			Expect(err).ToNot(HaveOccurred(), out, stderr)
+			mutatedMd5sum := strings.Split(out, " ")[0]
+			immediate := int64(0)
+			err = virtClient.CoreV1().Pods(pod.Namespace).Delete(context.Background(), pod.Name, metav1.DeleteOptions{GracePeriodSeconds: &immediate})
+			Expect(err).ToNot(HaveOccurred())
+
+			By("Exporting the incremental delta against the base")
+			baseApiGroup := "export.kubevirt.io"
+			baseExportRef := &k8sv1.TypedLocalObjectReference{
+				APIGroup: &baseApiGroup,
+				Kind:     "VirtualMachineExport",
+				Name:     baseExport.Name,
+			}
+			deltaExport := createExportForPvc(pvc, fmt.Sprintf("delta-export-%s", pvc.Name), baseExportRef)
+
+			var baseUrl, deltaUrl, manifestUrl string
+			for _, volume := range deltaExport.Status.Links.Internal.Volumes {
+				if volume.Name != pvc.Name {
+					continue
+				}
+				for _, format := range volume.Formats {
+					switch format.Format {
+					case exportv1.KubeVirtQcow2Incremental:
+						deltaUrl = fmt.Sprintf(kubevirtcontentUrlTemplate, format.Url, token.Name)
+						manifestUrl = fmt.Sprintf(kubevirtcontentUrlTemplate, strings.Replace(format.Url, filepath.Base(format.Url), "manifest.json", 1), token.Name)
+					}
+				}
+			}
+			for _, volume := range baseExport.Status.Links.Internal.Volumes {
+				if volume.Name != pvc.Name {
+					continue
+				}
+				for _, format := range volume.Formats {
+					if format.Format == exportv1.KubeVirtRaw {
+						baseUrl = fmt.Sprintf(kubevirtcontentUrlTemplate, format.Url, token.Name)
+					}
+				}
+			}
+			Expect(baseUrl).ToNot(BeEmpty())
+			Expect(deltaUrl).ToNot(BeEmpty())
+			Expect(manifestUrl).ToNot(BeEmpty())
+
+			By("Downloading and reassembling the chain on the consumer side")
+			targetPvc := &k8sv1.PersistentVolumeClaim{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      fmt.Sprintf("target-pvc-%s", pvc.Name),
+					Namespace: pvc.Namespace,
+				},
+				Spec: k8sv1.PersistentVolumeClaimSpec{
+					AccessModes:      pvc.Spec.AccessModes,
+					StorageClassName: pvc.Spec.StorageClassName,
+					Resources:        pvc.Spec.Resources,
+				},
+			}
+			targetPvc, err = virtClient.CoreV1().PersistentVolumeClaims(targetPvc.Namespace).Create(context.Background(), targetPvc, metav1.CreateOptions{})
+			Expect(err).ToNot(HaveOccurred())
+			caConfigMap := createCaConfigMap("export-cacerts", targetPvc.Namespace, deltaExport.Status.Links.Internal.Cert)
+			downloadPod := createDownloadPodForPvc(targetPvc, caConfigMap)
+
+			downloadCommand := []string{
+				"/bin/sh", "-c",
+				fmt.Sprintf(
+					"curl -L --cacert %[1]s '%[2]s' --output %[4]s/base.img && "+
+						"curl -L --cacert %[1]s '%[3]s' --output %[4]s/delta.qcow2 && "+
+						"qemu-img rebase -u -b %[4]s/base.img -F raw %[4]s/delta.qcow2 && "+
+						"qemu-img convert -O raw %[4]s/delta.qcow2 %[4]s/restored.img",
+					filepath.Join(caCertPath, caBundleKey), baseUrl, deltaUrl, dataPath,
+				),
+			}
+			out, stderr, err = tests.ExecuteCommandOnPodV2(virtClient, downloadPod, downloadPod.Spec.Containers[0].Name, downloadCommand)
+			Expect(err).ToNot(HaveOccurred(), out, stderr)
+
+			verifyKubeVirtRawContent("restored.img", mutatedMd5sum, downloadPod)
+		})
+	})
+
+	Context("with an OCI image layout export format", func() {
+		It("should export an OCI image layout that can be pushed and re-imported as a containerDisk", func() {
+			sc, exists := tests.GetRWOFileSystemStorageClass()
+			if !exists {
+				Skip("Skip test when Filesystem storage is not present")
+			}
+
+			pvc, md5sum := populateKubeVirtContent(sc)
+			token = createExportTokenSecret(pvc)
+
+			apiGroup := "v1"
+			vmExport := &exportv1.VirtualMachineExport{
+				ObjectMeta: metav1.ObjectMeta{
+					Name:      fmt.Sprintf("test-export-%s", pvc.Name),
+					Namespace: pvc.Namespace,
+				},
+				Spec: exportv1.VirtualMachineExportSpec{
+					TokenSecretRef: token.Name,
+					Source: k8sv1.TypedLocalObjectReference{
+						APIGroup: &apiGroup,
+						Kind:     "PersistentVolumeClaim",
+						Name:     pvc.Name,
+					},
+				},
+			}
+			export, err := virtClient.VirtualMachineExport(pvc.Namespace).Create(context.Background(), vmExport, metav1.CreateOptions{})
+			Expect(err).ToNot(HaveOccurred())
+
+			Eventually(func() bool {
+				export, err = virtClient.VirtualMachineExport(pvc.Namespace).Get(context.Background(), export.Name, metav1.GetOptions{})
+				Expect(err).ToNot(HaveOccurred())
+				condReady := false
+				if export.Status != nil {
+					for _, cond := range export.Status.Conditions {
+						if cond.Type == exportv1.ConditionReady && cond.Status == k8sv1.ConditionTrue {
+							condReady = true
+						}
+					}
+				}
+				return condReady
+			}, 30*time.Second, 1*time.Second).Should(BeTrue())
+
+			ociUrl := ""
+			for _, volume := range export.Status.Links.Internal.Volumes {
+				if volume.Name == pvc.Name {
+					for _, format := range volume.Formats {
+						if format.Format == exportv1.OCIImageLayout {
+							ociUrl = fmt.Sprintf(kubevirtcontentUrlTemplate, format.Url, token.Name)
+						}
+					}
+				}
+			}
+			Expect(ociUrl).ToNot(BeEmpty())
+
+			By("Creating a pod that copies the OCI layout into the in-cluster registry")
+			registryImage := fmt.Sprintf("%s/export-%s:latest", flags.KubeVirtInsecureRegistry, pvc.Name)
+			caConfigMap := createCaConfigMap("export-cacerts", pvc.Namespace, export.Status.Links.Internal.Cert)
+			downloadPod := tests.RenderPod("skopeo-copy", []string{"/bin/sh", "-c", "sleep 360"}, []string{})
+			downloadPod.Spec.Volumes = append(downloadPod.Spec.Volumes, k8sv1.Volume{
+				Name: "cacerts",
+				VolumeSource: k8sv1.VolumeSource{
+					ConfigMap: &k8sv1.ConfigMapVolumeSource{
+						LocalObjectReference: k8sv1.LocalObjectReference{Name: caConfigMap.Name},
+					},
+				},
+			})
+			downloadPod.Spec.Containers[0].VolumeMounts = append(downloadPod.Spec.Containers[0].VolumeMounts, k8sv1.VolumeMount{
+				Name:      "cacerts",
+				ReadOnly:  true,
+				MountPath: "/cacerts",
+			})
+			downloadPod = tests.RunPod(downloadPod)
+
+			command := []string{
+				"/bin/sh", "-c",
+				fmt.Sprintf(
+					"curl -L --cacert %s '%s' --output /tmp/export-oci.tar && "+
+						"skopeo copy --dest-tls-verify=false oci-archive:/tmp/export-oci.tar docker://%s",
+					filepath.Join(caCertPath, caBundleKey), ociUrl, registryImage,
+				),
+			}
+			out, stderr, err := tests.ExecuteCommandOnPodV2(virtClient, downloadPod, downloadPod.Spec.Containers[0].Name, command)
+			Expect(err).ToNot(HaveOccurred(), out, stderr)
+
+			By("Booting a VMI from the re-imported containerDisk to confirm round-trip fidelity")
+			vmi := tests.NewRandomVMIWithEphemeralDiskAndUserdata(registryImage, "#!/bin/sh\necho hi\n")
+			vmi = tests.RunVMIAndExpectLaunch(vmi, 180)
+
+			pod, err := tests.GetRunningPodByVirtualMachineInstance(vmi, vmi.Namespace)
+			Expect(err).ToNot(HaveOccurred())
+			out, stderr, err = tests.ExecuteCommandOnPodV2(virtClient, pod, "compute", []string{"md5sum", "/var/run/kubevirt-private/vmi-disks/disk0/disk.img"})
+			Expect(err).ToNot(HaveOccurred(), out, stderr)
+			Expect(strings.Split(out, " ")[0]).To(Equal(md5sum))
+		})
+	})
 })